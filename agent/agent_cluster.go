@@ -0,0 +1,161 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mitre/gocat/cluster"
+	"github.com/mitre/gocat/output"
+)
+
+// JoinCluster starts the background subscriber that lets this agent share
+// state - successful comms channels, cached payload bytes - with siblings in
+// the same operation group over cfg's pub/sub backend.
+// cfg["backend"] selects "nats", "redis", or "memory" (the default).
+// cfg["address"] is passed to that backend (a NATS/Redis server address).
+func (a *Agent) JoinCluster(cfg map[string]string) error {
+	backend, err := newClusterBackend(cfg)
+	if err != nil {
+		return err
+	}
+	a.clusterBackend = backend
+	a.clusterTopic = cluster.Topic(a.group)
+	a.peerPayloadCache = make(map[string][]byte)
+	a.claimedInstructions = make(map[string]bool)
+	a.clusterAdoptions = make(chan cluster.CommsChannelEvent, clusterAdoptionBacklog)
+	output.VerbosePrint(fmt.Sprintf("[*] Joining cluster on topic %s", a.clusterTopic))
+	return backend.Subscribe(a.clusterTopic, a.handleClusterEvent)
+}
+
+// clusterAdoptionBacklog bounds how many un-applied peer comms-channel
+// adoptions the agent will queue up between Beacon() calls; once it is full,
+// newly arriving adoptions are dropped and the queued ones are kept.
+const clusterAdoptionBacklog = 8
+
+func newClusterBackend(cfg map[string]string) (cluster.Backend, error) {
+	switch cfg["backend"] {
+	case "nats":
+		return cluster.NewNatsBackend(cfg["address"])
+	case "redis":
+		return cluster.NewRedisBackend(cfg["address"])
+	case "memory", "":
+		return cluster.NewMemoryBackend(), nil
+	default:
+		return nil, errors.New(fmt.Sprintf("unknown cluster backend: %s", cfg["backend"]))
+	}
+}
+
+// handleClusterEvent runs on the pub/sub backend's own subscriber goroutine
+// (for MemoryBackend, a bare `go handler(data)` per publish), concurrently
+// with the agent's single-threaded Beacon()/SetCommunicationChannels() loop.
+// It must never itself mutate agentComms/beaconContact/validatedCommsChannels
+// or anything else that loop touches unsynchronized - so a comms-channel
+// adoption is only queued here, and applied later from applyPendingClusterAdoptions
+// on the agent's own goroutine. Payload caching is fine to do inline since
+// peerPayloadCache is already its own clusterMu-guarded map untouched by the
+// main loop.
+func (a *Agent) handleClusterEvent(data []byte) {
+	var event cluster.Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		output.VerbosePrint(fmt.Sprintf("[-] Dropping malformed cluster event: %s", err.Error()))
+		return
+	}
+	// Claims are honoured whatever their source, so siblings sharing a paw
+	// still skip each other's instructions.
+	if event.SourcePaw == a.paw && event.Type != cluster.EventInstructionClaimed {
+		return
+	}
+	switch event.Type {
+	case cluster.EventCommsChannel:
+		if event.CommsChannel == nil {
+			return
+		}
+		select {
+		case a.clusterAdoptions <- *event.CommsChannel:
+		default:
+			output.VerbosePrint(fmt.Sprintf("[!] Dropping cluster peer %s's %s channel: adoption backlog is full.", event.SourcePaw, event.CommsChannel.Protocol))
+		}
+	case cluster.EventPayloadCached:
+		if event.Payload == nil {
+			return
+		}
+		a.clusterMu.Lock()
+		a.peerPayloadCache[event.Payload.Name] = event.Payload.Data
+		a.clusterMu.Unlock()
+	case cluster.EventInstructionClaimed:
+		if len(event.InstructionID) == 0 {
+			return
+		}
+		a.clusterMu.Lock()
+		if a.claimedInstructions == nil {
+			a.claimedInstructions = make(map[string]bool)
+		}
+		a.claimedInstructions[event.InstructionID] = true
+		a.clusterMu.Unlock()
+	}
+}
+
+// applyPendingClusterAdoptions drains any comms-channel adoptions queued by
+// handleClusterEvent and applies them on the caller's goroutine. Beacon()
+// calls this at the start of every iteration so adoption happens serialized
+// with the rest of the agent's comms-channel state, never racing it.
+func (a *Agent) applyPendingClusterAdoptions() {
+	for {
+		select {
+		case event := <-a.clusterAdoptions:
+			output.VerbosePrint(fmt.Sprintf("[*] Adopting cluster peer's working %s channel.", event.Protocol))
+			if err := a.ValidateAndSetCommsChannel(event.Address, event.Protocol, event.Key); err != nil {
+				output.VerbosePrint(fmt.Sprintf("[-] Could not adopt cluster peer's comms channel: %s", err.Error()))
+			}
+		default:
+			return
+		}
+	}
+}
+
+// publishClusterEvent is a no-op until the agent has joined a cluster.
+func (a *Agent) publishClusterEvent(event cluster.Event) {
+	if a.clusterBackend == nil {
+		return
+	}
+	event.SourcePaw = a.paw
+	data, err := json.Marshal(event)
+	if err != nil {
+		output.VerbosePrint(fmt.Sprintf("[-] Could not marshal cluster event: %s", err.Error()))
+		return
+	}
+	if err := a.clusterBackend.Publish(a.clusterTopic, data); err != nil {
+		output.VerbosePrint(fmt.Sprintf("[-] Could not publish cluster event: %s", err.Error()))
+	}
+}
+
+// claimInstruction returns true if instructionID was already claimed by this
+// agent or a cluster sibling (so RunInstruction should skip it), and
+// otherwise claims it locally and announces the claim to the cluster so
+// siblings sharing a paw skip it too.
+func (a *Agent) claimInstruction(instructionID string) bool {
+	a.clusterMu.Lock()
+	if a.claimedInstructions == nil {
+		a.claimedInstructions = make(map[string]bool)
+	}
+	if a.claimedInstructions[instructionID] {
+		a.clusterMu.Unlock()
+		return true
+	}
+	a.claimedInstructions[instructionID] = true
+	a.clusterMu.Unlock()
+	a.publishClusterEvent(cluster.Event{Type: cluster.EventInstructionClaimed, InstructionID: instructionID})
+	return false
+}
+
+// peerCachedPayload returns payload bytes a cluster peer already fetched, if any.
+func (a *Agent) peerCachedPayload(name string) ([]byte, bool) {
+	if a.peerPayloadCache == nil {
+		return nil, false
+	}
+	a.clusterMu.Lock()
+	defer a.clusterMu.Unlock()
+	data, ok := a.peerPayloadCache[name]
+	return data, ok
+}
@@ -4,16 +4,25 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/mitre/gocat/cluster"
 	"github.com/mitre/gocat/contact"
 	"github.com/mitre/gocat/output"
 )
 
+// P2pReceiver is kept up to date with whichever comms channel the agent is
+// currently beaconing over, so it can forward peer traffic upstream.
+type P2pReceiver interface {
+	UpdateUpstreamComs(contactObj contact.Contact)
+	UpdateUpstreamServer(address string)
+}
+
 type AgentCommsChannel struct {
 	address string
 	c2Protocol string
 	c2Key string
 	contactObj contact.Contact
 	validated bool
+	sniffedProtocol string
 }
 
 // AgentCommsChannel methods
@@ -90,6 +99,12 @@ func (a *AgentCommsChannel) GetIdentifier() string {
 	return fmt.Sprintf("%s-%s", a.c2Protocol, a.address)
 }
 
+// GetSniffedProtocol returns the protocol that won a sniffing probe for
+// this channel, or "" if it wasn't selected that way.
+func (a *AgentCommsChannel) GetSniffedProtocol() string {
+	return a.sniffedProtocol
+}
+
 // Agent methods
 
 func (a *Agent) GetCurrentContact() contact.Contact {
@@ -101,7 +116,11 @@ func (a *Agent) getCurrentServerAddress() string {
 }
 
 func (a *Agent) GetCurrentContactName() string {
-	return a.agentComms.GetContactName()
+	name := a.agentComms.GetContactName()
+	if sniffed := a.agentComms.GetSniffedProtocol(); len(sniffed) > 0 {
+		return fmt.Sprintf("%s (sniffed: %s)", name, sniffed)
+	}
+	return name
 }
 
 func (a *Agent) getCurrentCommsProtocol() string {
@@ -145,12 +164,21 @@ func (a *Agent) GetCommunicationChannel(server string, c2Protocol string, c2Key
 	return commsChannel, nil
 }
 
+// ValidateAndSetCommsChannel validates the requested channel, retrying with
+// a configurable RetryPolicy (falling through previously successful
+// channels and finally HTTP) until it succeeds or the policy's MaxElapsed
+// deadline is reached. This applies to the sniffing sentinel too, so a
+// transient failure while probing candidate protocols gets the same
+// backoff/fallback chain as validating a single named protocol.
 func (a *Agent) ValidateAndSetCommsChannel(server string, c2Protocol string, c2Key string) error {
+	if c2Protocol == sniffProtocol {
+		return a.sniffAndSetCommsChannelWithRetry(server, c2Key)
+	}
 	commsChannel, err := a.GetCommunicationChannel(server, c2Protocol, c2Key)
 	if err != nil {
 		return err
 	}
-	return a.validateAndSetCommsChannelObj(commsChannel)
+	return a.validateAndSetCommsChannelWithRetry(commsChannel)
 }
 
 func (a *Agent) validateAndSetCommsChannelObj(commsChannel AgentCommsChannel) error {
@@ -166,12 +194,22 @@ func (a *Agent) validateAndSetCommsChannelObj(commsChannel AgentCommsChannel) er
 	}
 }
 
+// modifyAgentConfiguration applies any profile overrides a comms channel's
+// C2RequirementsMet check requested (e.g. a paw reassignment from the server).
+func (a *Agent) modifyAgentConfiguration(modifications map[string]string) {
+	if paw, ok := modifications["paw"]; ok {
+		a.SetPaw(paw)
+	}
+}
+
 func (a *Agent) setCommsChannel(commsChannel AgentCommsChannel, profileModifications map[string]string) {
 	a.addValidatedCommsChannel(commsChannel)
 	if profileModifications != nil {
 		a.modifyAgentConfiguration(profileModifications)
 	}
 	a.agentComms = commsChannel
+	a.beaconContact = commsChannel.GetContact()
+	a.heartbeatContact = commsChannel.GetContact()
 	if a.localP2pReceivers != nil {
 		for _, receiver := range a.localP2pReceivers {
 			receiver.UpdateUpstreamComs(commsChannel.GetContact())
@@ -195,6 +233,16 @@ func (a *Agent) UpdateSuccessfulContacts() {
 		a.successfulCommsChannels = append(a.successfulCommsChannels, a.agentComms)
 		output.VerbosePrint(fmt.Sprintf("[*] Added comms channel to historical list of successful contacts: ", a.agentComms.GetIdentifier()))
 		a.tryingSwitchedContact = false
+
+		// Let cluster siblings adopt this channel instead of re-probing.
+		a.publishClusterEvent(cluster.Event{
+			Type: cluster.EventCommsChannel,
+			CommsChannel: &cluster.CommsChannelEvent{
+				Protocol: a.agentComms.GetProtocol(),
+				Address: a.agentComms.GetAddress(),
+				Key: a.agentComms.GetKey(),
+			},
+		})
 	}
 }
 
@@ -0,0 +1,187 @@
+package agent
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/mitre/gocat/output"
+)
+
+// jwks mirrors the subset of RFC 7517 this agent needs: an RSA signing key
+// published by the C2 server so instructions can be verified without a
+// shared secret.
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// setUpJWTTrust parses the trusted public key the C2 will sign instructions
+// with, from either a PEM blob (c2Config["jwtPublicKey"]) or a JWKS endpoint
+// (c2Config["jwtJwksUrl"]) fetched once and cached for the life of the agent.
+// c2Config["jwtRequired"] set to "true" rejects any unsigned instruction.
+func (a *Agent) setUpJWTTrust(c2Config map[string]string) error {
+	a.jwtRequired = c2Config["jwtRequired"] == "true"
+	if pemKey, ok := c2Config["jwtPublicKey"]; ok && len(pemKey) > 0 {
+		key, err := parseJWTPublicKeyPEM(pemKey)
+		if err != nil {
+			return err
+		}
+		a.jwtPublicKey = key
+		a.jwtKeySource = "pem"
+		return nil
+	}
+	if jwksUrl, ok := c2Config["jwtJwksUrl"]; ok && len(jwksUrl) > 0 {
+		key, err := fetchJWKSPublicKey(jwksUrl)
+		if err != nil {
+			return err
+		}
+		a.jwtPublicKey = key
+		a.jwtKeySource = "jwks:" + jwksUrl
+		return nil
+	}
+	if a.jwtRequired {
+		return errors.New("jwtRequired is set but no jwtPublicKey or jwtJwksUrl was provided")
+	}
+	return nil
+}
+
+// parseJWTPublicKeyPEM accepts either an RSA or ECDSA PEM-encoded public key.
+func parseJWTPublicKeyPEM(pemData string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(pemData))
+	if block == nil {
+		return nil, errors.New("jwtPublicKey does not contain a valid PEM block")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse jwtPublicKey: %w", err)
+	}
+	return key, nil
+}
+
+// fetchJWKSPublicKey retrieves a JWKS document once and returns the first RSA
+// key found, as the verifying *rsa.PublicKey.
+func fetchJWKSPublicKey(jwksUrl string) (interface{}, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(jwksUrl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	var keySet jwks
+	if err := json.NewDecoder(resp.Body).Decode(&keySet); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+	for _, key := range keySet.Keys {
+		if key.Kty != "RSA" {
+			continue
+		}
+		nBytes, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			continue
+		}
+		eBytes, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			continue
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(nBytes),
+			E: int(new(big.Int).SetBytes(eBytes).Int64()),
+		}, nil
+	}
+	return nil, errors.New("JWKS did not contain an RSA key")
+}
+
+// allowedJWTMethods pins the signing algorithms accepted for the configured
+// key's type, so a compromised C2 can't re-sign instructions under an
+// unexpected algorithm (e.g. "none", or HMAC with the public key bytes as
+// the secret) that the keyfunc in verifySignedInstruction would otherwise
+// hand the key to blindly.
+func allowedJWTMethods(key interface{}) []string {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+	case *ecdsa.PublicKey:
+		return []string{"ES256", "ES384", "ES512"}
+	default:
+		return nil
+	}
+}
+
+// verifySignedInstruction checks an individual instruction's "jwt" field,
+// if present, against the agent's trusted key: signature, aud == this
+// agent's paw, and exp not elapsed (all enforced by the jwt library itself).
+// It returns the instruction unchanged if it may be dispatched, or false if
+// it must be dropped.
+func (a *Agent) verifySignedInstruction(rawInstruction interface{}) (interface{}, bool) {
+	instruction, ok := rawInstruction.(map[string]interface{})
+	if !ok {
+		return rawInstruction, true
+	}
+	tokenString, hasToken := instruction["jwt"].(string)
+	if !hasToken || len(tokenString) == 0 {
+		if a.jwtRequired {
+			output.VerbosePrint("[-] Dropping unsigned instruction: jwtRequired is enabled.")
+			return nil, false
+		}
+		return instruction, true
+	}
+	if a.jwtPublicKey == nil {
+		output.VerbosePrint("[-] Dropping signed instruction: no trusted JWT public key configured.")
+		return nil, false
+	}
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return a.jwtPublicKey, nil
+	}, jwt.WithValidMethods(allowedJWTMethods(a.jwtPublicKey)), jwt.WithAudience(a.paw))
+	if err != nil {
+		output.VerbosePrint(fmt.Sprintf("[-] Dropping instruction: JWT verification failed for paw %s: %s", a.paw, err.Error()))
+		return nil, false
+	}
+	return instruction, true
+}
+
+// filterSignedInstructions drops any instruction that fails JWT verification
+// and returns the remaining ones in their original order.
+func (a *Agent) filterSignedInstructions(commands interface{}) interface{} {
+	commandList, ok := commands.([]interface{})
+	if !ok {
+		return commands
+	}
+	var verified []interface{}
+	for _, rawInstruction := range commandList {
+		if instruction, ok := a.verifySignedInstruction(rawInstruction); ok {
+			verified = append(verified, instruction)
+		}
+	}
+	return verified
+}
+
+// jwtTrustState describes the agent's current JWT trust configuration for
+// Display().
+func (a *Agent) jwtTrustState() string {
+	if a.jwtPublicKey == nil {
+		if a.jwtRequired {
+			return "required, no key configured (instructions will be dropped)"
+		}
+		return "disabled"
+	}
+	state := fmt.Sprintf("trusted key=%s", a.jwtKeySource)
+	if a.jwtRequired {
+		state += ", required"
+	}
+	return state
+}
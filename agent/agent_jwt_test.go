@@ -0,0 +1,99 @@
+package agent
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func generateTestRSAKeyPair(t *testing.T) (*rsa.PrivateKey, *rsa.PublicKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	return key, &key.PublicKey
+}
+
+func signTestToken(t *testing.T, key *rsa.PrivateKey, aud string, expiresAt time.Time) string {
+	t.Helper()
+	claims := jwt.MapClaims{
+		"aud": aud,
+		"exp": expiresAt.Unix(),
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign test token: %v", err)
+	}
+	return signed
+}
+
+func TestVerifySignedInstructionAcceptsValidToken(t *testing.T) {
+	privateKey, publicKey := generateTestRSAKeyPair(t)
+	a := &Agent{paw: "test-paw", jwtPublicKey: publicKey}
+	token := signTestToken(t, privateKey, "test-paw", time.Now().Add(time.Minute))
+	instruction := map[string]interface{}{"id": "1", "jwt": token}
+
+	result, ok := a.verifySignedInstruction(instruction)
+	if !ok {
+		t.Fatal("expected a validly signed instruction to be accepted")
+	}
+	if result.(map[string]interface{})["id"] != "1" {
+		t.Fatal("expected the instruction to be returned unchanged")
+	}
+}
+
+func TestVerifySignedInstructionDropsExpiredToken(t *testing.T) {
+	privateKey, publicKey := generateTestRSAKeyPair(t)
+	a := &Agent{paw: "test-paw", jwtPublicKey: publicKey}
+	token := signTestToken(t, privateKey, "test-paw", time.Now().Add(-time.Minute))
+	instruction := map[string]interface{}{"id": "1", "jwt": token}
+
+	if _, ok := a.verifySignedInstruction(instruction); ok {
+		t.Fatal("expected an expired token to be dropped")
+	}
+}
+
+func TestVerifySignedInstructionDropsWrongAudience(t *testing.T) {
+	privateKey, publicKey := generateTestRSAKeyPair(t)
+	a := &Agent{paw: "test-paw", jwtPublicKey: publicKey}
+	token := signTestToken(t, privateKey, "someone-elses-paw", time.Now().Add(time.Minute))
+	instruction := map[string]interface{}{"id": "1", "jwt": token}
+
+	if _, ok := a.verifySignedInstruction(instruction); ok {
+		t.Fatal("expected an instruction signed for a different paw to be dropped")
+	}
+}
+
+func TestVerifySignedInstructionDropsBadSignature(t *testing.T) {
+	_, publicKey := generateTestRSAKeyPair(t)
+	otherPrivateKey, _ := generateTestRSAKeyPair(t)
+	a := &Agent{paw: "test-paw", jwtPublicKey: publicKey}
+	token := signTestToken(t, otherPrivateKey, "test-paw", time.Now().Add(time.Minute))
+	instruction := map[string]interface{}{"id": "1", "jwt": token}
+
+	if _, ok := a.verifySignedInstruction(instruction); ok {
+		t.Fatal("expected an instruction signed by an untrusted key to be dropped")
+	}
+}
+
+func TestVerifySignedInstructionRequiresSignatureWhenConfigured(t *testing.T) {
+	a := &Agent{paw: "test-paw", jwtRequired: true}
+	instruction := map[string]interface{}{"id": "1"}
+
+	if _, ok := a.verifySignedInstruction(instruction); ok {
+		t.Fatal("expected an unsigned instruction to be dropped when jwtRequired is set")
+	}
+}
+
+func TestVerifySignedInstructionAllowsUnsignedWhenNotRequired(t *testing.T) {
+	a := &Agent{paw: "test-paw"}
+	instruction := map[string]interface{}{"id": "1"}
+
+	if _, ok := a.verifySignedInstruction(instruction); !ok {
+		t.Fatal("expected an unsigned instruction to pass through when jwtRequired is not set")
+	}
+}
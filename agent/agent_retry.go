@@ -0,0 +1,141 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitre/gocat/output"
+)
+
+// RetryPolicy configures how persistently the agent keeps trying to
+// validate a comms channel (or land a beacon) before giving up, modeled
+// after goss's retry-timeout/sleep flags.
+type RetryPolicy struct {
+	Sleep      time.Duration
+	MaxElapsed time.Duration
+	Backoff    float64
+	Jitter     time.Duration
+}
+
+// DefaultRetryPolicy keeps retrying for a minute, starting at a 1s sleep
+// and doubling (plus up to half a second of jitter) each attempt.
+var DefaultRetryPolicy = RetryPolicy{
+	Sleep:      time.Second,
+	MaxElapsed: 60 * time.Second,
+	Backoff:    2.0,
+	Jitter:     500 * time.Millisecond,
+}
+
+// retryPolicyFromConfig overlays any c2Config["retry*"] overrides onto DefaultRetryPolicy.
+func retryPolicyFromConfig(c2Config map[string]string) RetryPolicy {
+	policy := DefaultRetryPolicy
+	if value, ok := c2Config["retrySleep"]; ok {
+		if sleep, err := time.ParseDuration(value); err == nil {
+			policy.Sleep = sleep
+		}
+	}
+	if value, ok := c2Config["retryMaxElapsed"]; ok {
+		if maxElapsed, err := time.ParseDuration(value); err == nil {
+			policy.MaxElapsed = maxElapsed
+		}
+	}
+	if value, ok := c2Config["retryBackoff"]; ok {
+		if backoff, err := strconv.ParseFloat(value, 64); err == nil {
+			policy.Backoff = backoff
+		}
+	}
+	if value, ok := c2Config["retryJitter"]; ok {
+		if jitter, err := time.ParseDuration(value); err == nil {
+			policy.Jitter = jitter
+		}
+	}
+	return policy
+}
+
+// nextSleep applies exponential backoff plus jitter to the previous sleep duration.
+func (p RetryPolicy) nextSleep(previousSleep time.Duration) time.Duration {
+	next := time.Duration(float64(previousSleep) * p.Backoff)
+	if p.Jitter > 0 {
+		next += time.Duration(rand.Int63n(int64(p.Jitter)))
+	}
+	return next
+}
+
+// retryUntilSuccess calls attempt until it succeeds or policy.MaxElapsed has
+// passed, sleeping with exponential backoff and jitter between attempts and
+// logging elapsed/timeout progress. describe names the target for logging.
+func (a *Agent) retryUntilSuccess(describe string, policy RetryPolicy, attempt func() error) error {
+	start := time.Now()
+	sleep := policy.Sleep
+	var lastErr error
+	for {
+		if err := attempt(); err == nil {
+			return nil
+		} else {
+			lastErr = err
+		}
+		elapsed := time.Since(start)
+		output.VerbosePrint(fmt.Sprintf("[-] %s failed (%s). elapsed/timeout: %s/%s", describe, lastErr.Error(), elapsed.Round(time.Millisecond), policy.MaxElapsed))
+		if elapsed >= policy.MaxElapsed {
+			return errors.New(fmt.Sprintf("Gave up on %s after %s: %s", describe, elapsed.Round(time.Millisecond), lastErr.Error()))
+		}
+		time.Sleep(sleep)
+		sleep = policy.nextSleep(sleep)
+	}
+}
+
+// validateAndSetCommsChannelWithRetry re-validates the requested channel on
+// every attempt and, each time that fails, falls through to a previously
+// successful channel and finally to HTTP, until one succeeds or the retry
+// policy's MaxElapsed deadline passes. A fallback that succeeds ends the
+// loop, so the requested channel is retried only while the fallbacks fail
+// too. The returned error names every channel that was tried so operators
+// can diagnose network partitions.
+func (a *Agent) validateAndSetCommsChannelWithRetry(commsChannel AgentCommsChannel) error {
+	var triedChannels []string
+	err := a.retryUntilSuccess(fmt.Sprintf("validating comms channel %s", commsChannel.GetIdentifier()), a.retryPolicy, func() error {
+		if err := a.validateAndSetCommsChannelObj(commsChannel); err == nil {
+			return nil
+		} else {
+			triedChannels = append(triedChannels, commsChannel.GetIdentifier())
+			if fallbackErr := a.switchToPreviousSuccessfulCommsChannel(); fallbackErr == nil {
+				return nil
+			}
+			if fallbackErr := a.SetDefaultCommunicationChannel(commsChannel.GetConfig()); fallbackErr == nil {
+				return nil
+			}
+			return err
+		}
+	})
+	if err != nil && len(triedChannels) > 0 {
+		return errors.New(fmt.Sprintf("%s. Channels tried: %s", err.Error(), strings.Join(triedChannels, ", ")))
+	}
+	return err
+}
+
+// sniffAndSetCommsChannelWithRetry re-probes candidate protocols under the
+// same RetryPolicy and fallback chain validateAndSetCommsChannelWithRetry
+// uses: each failed sniff falls through to a previously successful channel
+// and then HTTP, and the loop backs off and sniffs again only while those
+// fallbacks fail too, until MaxElapsed. A fallback that succeeds ends the
+// loop without another sniff.
+func (a *Agent) sniffAndSetCommsChannelWithRetry(server string, c2Key string) error {
+	return a.retryUntilSuccess(fmt.Sprintf("sniffing C2 channel for %s", server), a.retryPolicy, func() error {
+		commsChannel, modifications, err := a.sniffCommsChannel(server, c2Key)
+		if err != nil {
+			if fallbackErr := a.switchToPreviousSuccessfulCommsChannel(); fallbackErr == nil {
+				return nil
+			}
+			if fallbackErr := a.SetDefaultCommunicationChannel(map[string]string{"c2Key": c2Key}); fallbackErr == nil {
+				return nil
+			}
+			return err
+		}
+		a.setCommsChannel(commsChannel, modifications)
+		return nil
+	})
+}
@@ -0,0 +1,61 @@
+package agent
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyNextSleepAppliesBackoff(t *testing.T) {
+	policy := RetryPolicy{Sleep: 10 * time.Millisecond, Backoff: 2.0, Jitter: 0}
+	next := policy.nextSleep(10 * time.Millisecond)
+	if next != 20*time.Millisecond {
+		t.Fatalf("expected backoff to double 10ms to 20ms, got %s", next)
+	}
+}
+
+func TestRetryPolicyNextSleepAddsBoundedJitter(t *testing.T) {
+	policy := RetryPolicy{Backoff: 1.0, Jitter: 10 * time.Millisecond}
+	for i := 0; i < 20; i++ {
+		next := policy.nextSleep(0)
+		if next < 0 || next >= 10*time.Millisecond {
+			t.Fatalf("jitter out of bounds [0, 10ms): got %s", next)
+		}
+	}
+}
+
+func TestRetryUntilSuccessGivesUpAfterMaxElapsed(t *testing.T) {
+	a := &Agent{}
+	policy := RetryPolicy{Sleep: 5 * time.Millisecond, MaxElapsed: 20 * time.Millisecond, Backoff: 1.0, Jitter: 0}
+	attempts := 0
+
+	err := a.retryUntilSuccess("test attempt", policy, func() error {
+		attempts++
+		return errors.New("always fails")
+	})
+
+	if err == nil {
+		t.Fatal("expected retryUntilSuccess to give up and return an error")
+	}
+	if attempts < 2 {
+		t.Fatalf("expected more than one attempt before giving up, got %d", attempts)
+	}
+}
+
+func TestRetryUntilSuccessReturnsNilOnFirstSuccess(t *testing.T) {
+	a := &Agent{}
+	policy := RetryPolicy{Sleep: time.Millisecond, MaxElapsed: time.Second, Backoff: 1.0}
+	attempts := 0
+
+	err := a.retryUntilSuccess("test attempt", policy, func() error {
+		attempts++
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("expected nil error on immediate success, got %v", err)
+	}
+	if attempts != 1 {
+		t.Fatalf("expected exactly one attempt, got %d", attempts)
+	}
+}
@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mitre/gocat/contact"
+	"github.com/mitre/gocat/output"
+)
+
+// sniffProtocol is the c2Name sentinel that asks the agent to probe every
+// registered contact.CommunicationChannels entry and pick the fastest one
+// that returns a valid beacon response, instead of trying a single named
+// protocol. Inspired by v2ray's dispatcher sniffing.
+const sniffProtocol = "sniff"
+
+const defaultSniffTimeout = 3 * time.Second
+
+// sniffOptionsFromConfig reads c2Config["sniffTimeout"] (a Go duration
+// string, default 3s) and c2Config["sniffOrder"] (a comma-separated list of
+// the protocols to probe, default: try every registered channel). The
+// fastest valid protocol wins whatever its position in that list.
+func sniffOptionsFromConfig(c2Config map[string]string) (time.Duration, []string) {
+	timeout := defaultSniffTimeout
+	if value, ok := c2Config["sniffTimeout"]; ok {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			timeout = parsed
+		}
+	}
+	var order []string
+	if value, ok := c2Config["sniffOrder"]; ok && len(value) > 0 {
+		order = strings.Split(value, ",")
+	}
+	return timeout, order
+}
+
+type sniffResult struct {
+	channel       AgentCommsChannel
+	modifications map[string]string
+	protocol      string
+	latency       time.Duration
+	err           error
+}
+
+// sniffCommsChannel dials every candidate protocol in parallel with
+// a.sniffTimeout, validates each against the C2, and returns the fastest one
+// that came back valid. Candidates default to every protocol registered in
+// contact.CommunicationChannels, or a.sniffOrder if one was configured.
+func (a *Agent) sniffCommsChannel(server string, c2Key string) (AgentCommsChannel, map[string]string, error) {
+	candidates := a.sniffOrder
+	if len(candidates) == 0 {
+		for name := range contact.CommunicationChannels {
+			candidates = append(candidates, name)
+		}
+	}
+	if len(candidates) == 0 {
+		return AgentCommsChannel{}, nil, errors.New("No registered C2 contacts available to sniff.")
+	}
+
+	results := make(chan sniffResult, len(candidates))
+	for _, protocol := range candidates {
+		go a.probeProtocol(server, protocol, c2Key, results)
+	}
+
+	var tried []string
+	var winner *sniffResult
+	for i := 0; i < len(candidates); i++ {
+		result := <-results
+		tried = append(tried, result.protocol)
+		if result.err != nil {
+			output.VerbosePrint(fmt.Sprintf("[-] Sniffing %s failed: %s", result.protocol, result.err.Error()))
+			continue
+		}
+		if winner == nil || result.latency < winner.latency {
+			winnerCopy := result
+			winner = &winnerCopy
+		}
+	}
+	if winner == nil {
+		return AgentCommsChannel{}, nil, errors.New(fmt.Sprintf("Sniffing found no working C2 channel. Tried: %s", strings.Join(tried, ", ")))
+	}
+	winner.channel.sniffedProtocol = winner.protocol
+	output.VerbosePrint(fmt.Sprintf("[+] Sniffed %s as fastest working C2 channel (%s)", winner.protocol, winner.latency))
+	return winner.channel, winner.modifications, nil
+}
+
+// probeProtocol builds a comms channel for protocol and validates it,
+// bounded by a.sniffTimeout, reporting the outcome on results.
+func (a *Agent) probeProtocol(server string, protocol string, c2Key string, results chan<- sniffResult) {
+	done := make(chan sniffResult, 1)
+	go func() {
+		start := time.Now()
+		channel, err := AgentCommsFactory(server, protocol, c2Key)
+		if err != nil {
+			done <- sniffResult{protocol: protocol, err: err}
+			return
+		}
+		valid, modifications := channel.Validate(a.GetFullProfile())
+		if !valid {
+			done <- sniffResult{protocol: protocol, err: errors.New("C2 requirements not met")}
+			return
+		}
+		done <- sniffResult{channel: *channel, modifications: modifications, protocol: protocol, latency: time.Since(start)}
+	}()
+	select {
+	case result := <-done:
+		results <- result
+	case <-time.After(a.sniffTimeout):
+		results <- sniffResult{protocol: protocol, err: errors.New(fmt.Sprintf("timed out after %s", a.sniffTimeout))}
+	}
+}
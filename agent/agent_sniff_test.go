@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mitre/gocat/contact"
+)
+
+// fakeSniffContact is a minimal contact.Contact used only to exercise
+// sniffCommsChannel's winner-selection logic against controllable latency
+// and validity, without dialing anything real.
+type fakeSniffContact struct {
+	name  string
+	delay time.Duration
+	valid bool
+}
+
+func (f *fakeSniffContact) GetName() string { return f.name }
+
+func (f *fakeSniffContact) C2RequirementsMet(profile map[string]interface{}, c2Config map[string]string) (bool, map[string]string) {
+	time.Sleep(f.delay)
+	return f.valid, nil
+}
+
+func (f *fakeSniffContact) GetBeaconBytes(profile map[string]interface{}) []byte { return nil }
+
+func (f *fakeSniffContact) GetPayloadBytes(profile map[string]interface{}, payload string) []byte {
+	return nil
+}
+
+func (f *fakeSniffContact) SendExecutionResults(profile map[string]interface{}, result map[string]interface{}) {
+}
+
+func registerFakeSniffContact(name string, delay time.Duration, valid bool) func() {
+	contact.CommunicationChannels[name] = &fakeSniffContact{name: name, delay: delay, valid: valid}
+	return func() { delete(contact.CommunicationChannels, name) }
+}
+
+func TestSniffCommsChannelPicksFastestValidProtocol(t *testing.T) {
+	defer registerFakeSniffContact("sniff-test-slow", 50*time.Millisecond, true)()
+	defer registerFakeSniffContact("sniff-test-fast", 5*time.Millisecond, true)()
+
+	a := &Agent{sniffTimeout: time.Second, sniffOrder: []string{"sniff-test-slow", "sniff-test-fast"}}
+	channel, _, err := a.sniffCommsChannel("example.com", "")
+	if err != nil {
+		t.Fatalf("expected a winning channel, got error: %v", err)
+	}
+	if channel.GetProtocol() != "sniff-test-fast" {
+		t.Fatalf("expected the fastest valid protocol to win, got %s", channel.GetProtocol())
+	}
+	if channel.GetSniffedProtocol() != "sniff-test-fast" {
+		t.Fatalf("expected the sniffed protocol to be recorded, got %s", channel.GetSniffedProtocol())
+	}
+}
+
+func TestSniffCommsChannelSkipsInvalidProtocols(t *testing.T) {
+	defer registerFakeSniffContact("sniff-test-invalid", 0, false)()
+	defer registerFakeSniffContact("sniff-test-valid", 0, true)()
+
+	a := &Agent{sniffTimeout: time.Second, sniffOrder: []string{"sniff-test-invalid", "sniff-test-valid"}}
+	channel, _, err := a.sniffCommsChannel("example.com", "")
+	if err != nil {
+		t.Fatalf("expected a winning channel, got error: %v", err)
+	}
+	if channel.GetProtocol() != "sniff-test-valid" {
+		t.Fatalf("expected the only valid protocol to win, got %s", channel.GetProtocol())
+	}
+}
+
+func TestSniffCommsChannelTimesOutSlowProtocols(t *testing.T) {
+	defer registerFakeSniffContact("sniff-test-timeout", time.Second, true)()
+
+	a := &Agent{sniffTimeout: 10 * time.Millisecond, sniffOrder: []string{"sniff-test-timeout"}}
+	if _, _, err := a.sniffCommsChannel("example.com", ""); err == nil {
+		t.Fatal("expected sniffing to time out and return an error")
+	}
+}
@@ -0,0 +1,58 @@
+// Package cluster lets multiple gocat agents in the same operation group
+// share state - successful comms channels, cached payload bytes, claimed
+// instruction IDs - over a pluggable async pub/sub backend, so one agent's
+// successful C2 probe or payload fetch saves every sibling from repeating it.
+package cluster
+
+import "fmt"
+
+// Backend is the pluggable async messaging transport clustered agents use.
+type Backend interface {
+	Publish(topic string, data []byte) error
+	Subscribe(topic string, handler func(data []byte)) error
+	Close() error
+}
+
+// EventType identifies what an Event carries.
+type EventType string
+
+const (
+	// EventCommsChannel announces a comms channel the publisher just
+	// validated, so siblings can adopt it instead of re-probing.
+	EventCommsChannel EventType = "comms_channel"
+	// EventPayloadCached carries payload bytes the publisher already fetched
+	// from C2, so siblings write those bytes instead of fetching them again.
+	// The bytes travel on the cluster topic; they are not served through P2P
+	// receivers.
+	EventPayloadCached EventType = "payload_cached"
+	// EventInstructionClaimed announces an instruction ID the publisher is
+	// about to execute, so siblings sharing a paw don't duplicate it.
+	EventInstructionClaimed EventType = "instruction_claimed"
+)
+
+// Event is the envelope published to a group's cluster topic.
+type Event struct {
+	Type          EventType          `json:"type"`
+	SourcePaw     string             `json:"source_paw"`
+	CommsChannel  *CommsChannelEvent `json:"comms_channel,omitempty"`
+	Payload       *PayloadEvent      `json:"payload,omitempty"`
+	InstructionID string             `json:"instruction_id,omitempty"`
+}
+
+// CommsChannelEvent carries enough of an AgentCommsChannel to reconstruct it.
+type CommsChannelEvent struct {
+	Protocol string `json:"protocol"`
+	Address  string `json:"address"`
+	Key      string `json:"key"`
+}
+
+// PayloadEvent carries a payload's cached bytes by name.
+type PayloadEvent struct {
+	Name string `json:"name"`
+	Data []byte `json:"data"`
+}
+
+// Topic returns the pub/sub topic siblings in the same operation group share.
+func Topic(group string) string {
+	return fmt.Sprintf("gocat.cluster.%s", group)
+}
@@ -0,0 +1,38 @@
+package cluster
+
+import "sync"
+
+// MemoryBackend fans events out to in-process subscribers only. It's the
+// default when no cluster backend is named - a cluster of one, or several
+// agents in the same process subscribed to one shared MemoryBackend.
+type MemoryBackend struct {
+	mu          sync.RWMutex
+	subscribers map[string][]func(data []byte)
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{subscribers: make(map[string][]func(data []byte))}
+}
+
+func (m *MemoryBackend) Publish(topic string, data []byte) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, handler := range m.subscribers[topic] {
+		go handler(data)
+	}
+	return nil
+}
+
+func (m *MemoryBackend) Subscribe(topic string, handler func(data []byte)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subscribers[topic] = append(m.subscribers[topic], handler)
+	return nil
+}
+
+func (m *MemoryBackend) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subscribers = make(map[string][]func(data []byte))
+	return nil
+}
@@ -0,0 +1,62 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryBackendPublishSubscribe(t *testing.T) {
+	backend := NewMemoryBackend()
+	received := make(chan []byte, 1)
+	if err := backend.Subscribe("topic", func(data []byte) {
+		received <- data
+	}); err != nil {
+		t.Fatalf("Subscribe returned error: %v", err)
+	}
+	if err := backend.Publish("topic", []byte("hello")); err != nil {
+		t.Fatalf("Publish returned error: %v", err)
+	}
+	select {
+	case data := <-received:
+		if string(data) != "hello" {
+			t.Fatalf("expected 'hello', got %q", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for published message")
+	}
+}
+
+func TestMemoryBackendIgnoresOtherTopics(t *testing.T) {
+	backend := NewMemoryBackend()
+	received := make(chan []byte, 1)
+	if err := backend.Subscribe("topic-a", func(data []byte) { received <- data }); err != nil {
+		t.Fatalf("Subscribe returned error: %v", err)
+	}
+	if err := backend.Publish("topic-b", []byte("nope")); err != nil {
+		t.Fatalf("Publish returned error: %v", err)
+	}
+	select {
+	case <-received:
+		t.Fatal("subscriber for topic-a should not receive topic-b events")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestMemoryBackendCloseStopsDelivery(t *testing.T) {
+	backend := NewMemoryBackend()
+	received := make(chan []byte, 1)
+	if err := backend.Subscribe("topic", func(data []byte) { received <- data }); err != nil {
+		t.Fatalf("Subscribe returned error: %v", err)
+	}
+	if err := backend.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if err := backend.Publish("topic", []byte("hello")); err != nil {
+		t.Fatalf("Publish returned error: %v", err)
+	}
+	select {
+	case <-received:
+		t.Fatal("subscriber should not receive events published after Close")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
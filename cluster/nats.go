@@ -0,0 +1,35 @@
+package cluster
+
+import (
+	"github.com/nats-io/nats.go"
+)
+
+// NatsBackend publishes/subscribes over a NATS server, for clusters that
+// span more than one subnet or already run NATS for other tooling.
+type NatsBackend struct {
+	conn *nats.Conn
+}
+
+func NewNatsBackend(address string) (*NatsBackend, error) {
+	conn, err := nats.Connect(address)
+	if err != nil {
+		return nil, err
+	}
+	return &NatsBackend{conn: conn}, nil
+}
+
+func (n *NatsBackend) Publish(topic string, data []byte) error {
+	return n.conn.Publish(topic, data)
+}
+
+func (n *NatsBackend) Subscribe(topic string, handler func(data []byte)) error {
+	_, err := n.conn.Subscribe(topic, func(msg *nats.Msg) {
+		handler(msg.Data)
+	})
+	return err
+}
+
+func (n *NatsBackend) Close() error {
+	n.conn.Close()
+	return nil
+}
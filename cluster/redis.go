@@ -0,0 +1,45 @@
+package cluster
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisBackend publishes/subscribes over Redis pub/sub, for operators who
+// already run Redis as shared infrastructure for an engagement.
+type RedisBackend struct {
+	client *redis.Client
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewRedisBackend(address string) (*RedisBackend, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	client := redis.NewClient(&redis.Options{Addr: address})
+	if err := client.Ping(ctx).Err(); err != nil {
+		cancel()
+		return nil, err
+	}
+	return &RedisBackend{client: client, ctx: ctx, cancel: cancel}, nil
+}
+
+func (r *RedisBackend) Publish(topic string, data []byte) error {
+	return r.client.Publish(r.ctx, topic, data).Err()
+}
+
+func (r *RedisBackend) Subscribe(topic string, handler func(data []byte)) error {
+	pubsub := r.client.Subscribe(r.ctx, topic)
+	channel := pubsub.Channel()
+	go func() {
+		for msg := range channel {
+			handler([]byte(msg.Payload))
+		}
+	}()
+	return nil
+}
+
+func (r *RedisBackend) Close() error {
+	r.cancel()
+	return r.client.Close()
+}
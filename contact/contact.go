@@ -0,0 +1,34 @@
+package contact
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Contact defines the interface that every C2 communication channel must implement.
+type Contact interface {
+	GetName() string
+	C2RequirementsMet(profile map[string]interface{}, c2Config map[string]string) (bool, map[string]string)
+	GetBeaconBytes(profile map[string]interface{}) []byte
+	GetPayloadBytes(profile map[string]interface{}, payload string) []byte
+	SendExecutionResults(profile map[string]interface{}, result map[string]interface{})
+}
+
+// PeerForwarder is an optional capability some Contact implementations (e.g.
+// the gRPC contact) support: relaying raw P2P bytes to another agent through
+// the current C2 channel, for an agent whose egress only reaches this one.
+type PeerForwarder interface {
+	ForwardToPeer(destination string, data []byte) ([]byte, error)
+}
+
+// CommunicationChannels holds every Contact implementation registered by name.
+// Individual contact packages add themselves here from their own init().
+var CommunicationChannels = make(map[string]Contact)
+
+// GetContactByName looks up a registered Contact by its c2Name.
+func GetContactByName(name string) (Contact, error) {
+	if contactObj, ok := CommunicationChannels[name]; ok {
+		return contactObj, nil
+	}
+	return nil, errors.New(fmt.Sprintf("Could not find requested C2 contact: %s", name))
+}
@@ -0,0 +1,301 @@
+// Package grpc implements the gRPC contact.Contact. Wire types are generated
+// from c2.proto; run `go generate ./...` after editing the proto file.
+package grpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative c2.proto
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/mitre/gocat/contact"
+	"github.com/mitre/gocat/contact/grpc/c2pb"
+	"github.com/mitre/gocat/output"
+)
+
+const (
+	Name = "gRPC"
+
+	dialTimeout   = 10 * time.Second
+	streamBackoff = 5 * time.Second
+)
+
+// GRPCContact speaks the C2 gRPC service defined in c2.proto. It prefers a
+// single long-lived bidirectional Beacon stream so instructions and results
+// flow in both directions without waiting on the next poll, and falls back
+// to the unary RPCs for beacons and payloads when the server or an
+// intermediate proxy refuses to let a stream stay open (common on
+// restrictive egress filters); execution results have no unary fallback.
+type GRPCContact struct {
+	connMu  sync.Mutex
+	address string
+	conn    *grpc.ClientConn
+	client  c2pb.C2Client
+
+	streamMu     sync.Mutex
+	stream       c2pb.C2_BeaconClient
+	streamFailed bool
+
+	pendingMu      sync.Mutex
+	pending        map[string]chan []byte // request_id -> channel the stream delivers that request's response on
+	requestCounter uint64
+}
+
+func init() {
+	contact.CommunicationChannels[Name] = &GRPCContact{}
+}
+
+func (g *GRPCContact) GetName() string {
+	return Name
+}
+
+// C2RequirementsMet dials the gRPC server and, if possible, opens the
+// bidirectional stream. c2Config["address"] is the gRPC target. An optional
+// c2Config["grpcCertPem"] pins the server certificate instead of trusting
+// the system root pool.
+//
+// CommunicationChannels registers a single package-level GRPCContact, and
+// chunk0-4's retry loop calls Validate (and so this method) again on every
+// retry attempt against that same instance. g.conn/g.client/g.address are
+// guarded by connMu so those retries can't race the drainStream/GetBeaconBytes
+// goroutines reading them, and the previous connection is closed (unblocking
+// its drainStream goroutine's stream.Recv()) instead of being leaked.
+func (g *GRPCContact) C2RequirementsMet(profile map[string]interface{}, c2Config map[string]string) (bool, map[string]string) {
+	address, ok := c2Config["address"]
+	if !ok || len(address) == 0 {
+		output.VerbosePrint("[-] gRPC contact requires an address in c2Config.")
+		return false, nil
+	}
+	creds, err := buildTransportCreds(c2Config)
+	if err != nil {
+		output.VerbosePrint(fmt.Sprintf("[-] gRPC contact failed to build TLS credentials: %s", err.Error()))
+		return false, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, address, grpc.WithTransportCredentials(creds), grpc.WithBlock())
+	if err != nil {
+		output.VerbosePrint(fmt.Sprintf("[-] gRPC contact could not reach %s: %s", address, err.Error()))
+		return false, nil
+	}
+
+	g.connMu.Lock()
+	previousConn := g.conn
+	g.address = address
+	g.conn = conn
+	g.client = c2pb.NewC2Client(conn)
+	g.connMu.Unlock()
+
+	g.pendingMu.Lock()
+	g.pending = make(map[string]chan []byte)
+	g.pendingMu.Unlock()
+
+	if previousConn != nil {
+		if err := previousConn.Close(); err != nil {
+			output.VerbosePrint(fmt.Sprintf("[-] gRPC contact failed to close previous connection: %s", err.Error()))
+		}
+	}
+
+	g.openStream()
+	return true, nil
+}
+
+// getClient returns the current client under connMu, so callers never read
+// g.client concurrently with C2RequirementsMet replacing it.
+func (g *GRPCContact) getClient() c2pb.C2Client {
+	g.connMu.Lock()
+	defer g.connMu.Unlock()
+	return g.client
+}
+
+// getAddress returns the current address under connMu, for the same reason as getClient.
+func (g *GRPCContact) getAddress() string {
+	g.connMu.Lock()
+	defer g.connMu.Unlock()
+	return g.address
+}
+
+// buildTransportCreds always returns an encrypted TLS transport: pinned to
+// c2Config["grpcCertPem"] when provided, or the system root pool otherwise.
+// Unlike InsecureSkipVerify, the server certificate is verified either way.
+// It never falls back to a fully unencrypted channel.
+func buildTransportCreds(c2Config map[string]string) (credentials.TransportCredentials, error) {
+	pinnedPem, ok := c2Config["grpcCertPem"]
+	if !ok || len(pinnedPem) == 0 {
+		return credentials.NewTLS(&tls.Config{}), nil
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(pinnedPem)) {
+		return nil, errors.New("grpcCertPem did not contain a valid PEM certificate")
+	}
+	return credentials.NewTLS(&tls.Config{RootCAs: pool}), nil
+}
+
+// openStream establishes the long-lived bidirectional stream and starts the
+// goroutine that drains server-pushed BeaconResponses into g.pending. If the
+// stream can't be opened, beacon calls fall back to BeaconUnary; execution
+// results are not sent, since the service has no unary RPC for them.
+func (g *GRPCContact) openStream() {
+	g.streamMu.Lock()
+	defer g.streamMu.Unlock()
+	stream, err := g.getClient().Beacon(context.Background())
+	if err != nil {
+		output.VerbosePrint(fmt.Sprintf("[-] gRPC contact could not open beacon stream, falling back to unary calls: %s", err.Error()))
+		g.streamFailed = true
+		return
+	}
+	g.stream = stream
+	g.streamFailed = false
+	go g.drainStream(stream)
+}
+
+func (g *GRPCContact) drainStream(stream c2pb.C2_BeaconClient) {
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			output.VerbosePrint(fmt.Sprintf("[-] gRPC beacon stream closed: %s", err.Error()))
+			g.streamMu.Lock()
+			g.streamFailed = true
+			g.streamMu.Unlock()
+			return
+		}
+		if resp := msg.GetResponse(); resp != nil {
+			g.pendingMu.Lock()
+			if responseCh, ok := g.pending[msg.GetRequestId()]; ok {
+				delete(g.pending, msg.GetRequestId())
+				responseCh <- resp.GetBeacon()
+			}
+			g.pendingMu.Unlock()
+		}
+	}
+}
+
+// nextRequestID returns a request ID unique to this connection, so a
+// response that arrives after its own GetBeaconBytes call already gave up
+// (e.g. to chunk0-4's retry loop) can't be mistaken for a later, unrelated
+// call's response.
+func (g *GRPCContact) nextRequestID() string {
+	return fmt.Sprintf("%s-%d", g.getAddress(), atomic.AddUint64(&g.requestCounter, 1))
+}
+
+// GetBeaconBytes pushes the profile over the open stream and waits for the
+// response correlated to this specific request. If the stream is down, or
+// no matching response shows up within streamBackoff, it falls back to
+// BeaconUnary.
+func (g *GRPCContact) GetBeaconBytes(profile map[string]interface{}) []byte {
+	profileBytes, err := json.Marshal(profile)
+	if err != nil {
+		output.VerbosePrint(fmt.Sprintf("[-] gRPC contact could not marshal profile: %s", err.Error()))
+		return nil
+	}
+	g.streamMu.Lock()
+	useStream := !g.streamFailed && g.stream != nil
+	stream := g.stream
+	g.streamMu.Unlock()
+	if useStream {
+		requestID := g.nextRequestID()
+		responseCh := make(chan []byte, 1)
+		g.pendingMu.Lock()
+		g.pending[requestID] = responseCh
+		g.pendingMu.Unlock()
+
+		if err := stream.Send(&c2pb.BeaconMessage{RequestId: requestID, Request: &c2pb.BeaconRequest{Profile: string(profileBytes)}}); err == nil {
+			if beacon := g.waitForStreamedBeacon(responseCh); beacon != nil {
+				return beacon
+			}
+		}
+		g.pendingMu.Lock()
+		delete(g.pending, requestID)
+		g.pendingMu.Unlock()
+		output.VerbosePrint("[-] gRPC beacon stream send failed, falling back to unary call.")
+	}
+	return g.getBeaconUnary(profileBytes)
+}
+
+func (g *GRPCContact) waitForStreamedBeacon(responseCh chan []byte) []byte {
+	select {
+	case beacon := <-responseCh:
+		return beacon
+	case <-time.After(streamBackoff):
+		return nil
+	}
+}
+
+func (g *GRPCContact) getBeaconUnary(profileBytes []byte) []byte {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	resp, err := g.getClient().BeaconUnary(ctx, &c2pb.BeaconRequest{Profile: string(profileBytes)})
+	if err != nil {
+		output.VerbosePrint(fmt.Sprintf("[-] gRPC unary beacon failed: %s", err.Error()))
+		return nil
+	}
+	return resp.GetBeacon()
+}
+
+// ForwardToPeer relays raw P2P payload bytes to another agent through the
+// same gRPC server, for agents whose egress only reaches this C2 channel.
+func (g *GRPCContact) ForwardToPeer(destination string, data []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	resp, err := g.getClient().ForwardPeer(ctx, &c2pb.PeerForwardRequest{Destination: destination, Data: data})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetData(), nil
+}
+
+func (g *GRPCContact) GetPayloadBytes(profile map[string]interface{}, payload string) []byte {
+	profileBytes, err := json.Marshal(profile)
+	if err != nil {
+		output.VerbosePrint(fmt.Sprintf("[-] gRPC contact could not marshal profile: %s", err.Error()))
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	resp, err := g.getClient().FetchPayload(ctx, &c2pb.PayloadRequest{Profile: string(profileBytes), Payload: payload})
+	if err != nil {
+		output.VerbosePrint(fmt.Sprintf("[-] gRPC contact failed to fetch payload %s: %s", payload, err.Error()))
+		return nil
+	}
+	return resp.GetData()
+}
+
+// SendExecutionResults pushes the result over the open stream. The service
+// has no unary RPC for results, so a result is dropped when the stream is
+// down, and a failed stream send is logged and dropped.
+func (g *GRPCContact) SendExecutionResults(profile map[string]interface{}, result map[string]interface{}) {
+	profileBytes, err := json.Marshal(profile)
+	if err != nil {
+		output.VerbosePrint(fmt.Sprintf("[-] gRPC contact could not marshal profile: %s", err.Error()))
+		return
+	}
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		output.VerbosePrint(fmt.Sprintf("[-] gRPC contact could not marshal result: %s", err.Error()))
+		return
+	}
+	g.streamMu.Lock()
+	useStream := !g.streamFailed && g.stream != nil
+	stream := g.stream
+	g.streamMu.Unlock()
+	if useStream {
+		if err := stream.Send(&c2pb.BeaconMessage{Result: &c2pb.ExecutionResult{Profile: string(profileBytes), Result: string(resultBytes)}}); err == nil {
+			return
+		}
+		output.VerbosePrint("[-] gRPC result stream send failed.")
+	}
+}
@@ -0,0 +1,104 @@
+package grpc
+
+import (
+	"io"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	"github.com/mitre/gocat/contact/grpc/c2pb"
+)
+
+func TestBuildTransportCredsDefaultsToSystemRootTLS(t *testing.T) {
+	creds, err := buildTransportCreds(map[string]string{})
+	if err != nil {
+		t.Fatalf("expected no error building default transport creds, got %v", err)
+	}
+	if creds.Info().SecurityProtocol != "tls" {
+		t.Fatalf("expected the no-pin default to still be TLS, got %q", creds.Info().SecurityProtocol)
+	}
+}
+
+func TestBuildTransportCredsAcceptsPinnedCert(t *testing.T) {
+	creds, err := buildTransportCreds(map[string]string{"grpcCertPem": testCertPem})
+	if err != nil {
+		t.Fatalf("expected no error building pinned transport creds, got %v", err)
+	}
+	if creds.Info().SecurityProtocol != "tls" {
+		t.Fatalf("expected pinned creds to still be TLS, got %q", creds.Info().SecurityProtocol)
+	}
+}
+
+func TestBuildTransportCredsRejectsInvalidPem(t *testing.T) {
+	if _, err := buildTransportCreds(map[string]string{"grpcCertPem": "not a cert"}); err == nil {
+		t.Fatal("expected an invalid grpcCertPem to return an error")
+	}
+}
+
+func TestNextRequestIDIsUniquePerCall(t *testing.T) {
+	g := &GRPCContact{address: "example.com:1234"}
+	first := g.nextRequestID()
+	second := g.nextRequestID()
+	if first == second {
+		t.Fatalf("expected successive request IDs to differ, both were %q", first)
+	}
+}
+
+// fakeBeaconStream is a minimal c2pb.C2_BeaconClient that replays a fixed
+// list of messages, used to exercise drainStream's request-ID correlation
+// without a live gRPC server.
+type fakeBeaconStream struct {
+	grpc.ClientStream
+	messages []*c2pb.BeaconMessage
+	idx      int
+}
+
+func (f *fakeBeaconStream) Send(m *c2pb.BeaconMessage) error { return nil }
+
+func (f *fakeBeaconStream) Recv() (*c2pb.BeaconMessage, error) {
+	if f.idx >= len(f.messages) {
+		return nil, io.EOF
+	}
+	m := f.messages[f.idx]
+	f.idx++
+	return m, nil
+}
+
+func TestDrainStreamCorrelatesResponseByRequestID(t *testing.T) {
+	g := &GRPCContact{pending: make(map[string]chan []byte)}
+	chA := make(chan []byte, 1)
+	chB := make(chan []byte, 1)
+	g.pending["a"] = chA
+	g.pending["b"] = chB
+
+	stream := &fakeBeaconStream{messages: []*c2pb.BeaconMessage{
+		{RequestId: "b", Response: &c2pb.BeaconResponse{Beacon: []byte("for-b")}},
+	}}
+	g.drainStream(stream)
+
+	select {
+	case data := <-chB:
+		if string(data) != "for-b" {
+			t.Fatalf("expected chB to receive %q, got %q", "for-b", data)
+		}
+	default:
+		t.Fatal("expected chB to receive the response correlated to request b")
+	}
+	select {
+	case data := <-chA:
+		t.Fatalf("expected chA to receive nothing, got %q", data)
+	default:
+	}
+}
+
+const testCertPem = `-----BEGIN CERTIFICATE-----
+MIIBgjCCASegAwIBAgIUBFj//djryDf/pXIJrUR5ILE2PmgwCgYIKoZIzj0EAwIw
+FjEUMBIGA1UEAwwLZXhhbXBsZS5jb20wHhcNMjYwNzI1MTkzMDE1WhcNMzYwNzIy
+MTkzMDE1WjAWMRQwEgYDVQQDDAtleGFtcGxlLmNvbTBZMBMGByqGSM49AgEGCCqG
+SM49AwEHA0IABCqATXu5tki1RSokmnoVvrHzkP3e5bryZth4MyeBvfKJJB7s1Tmd
+upYjhglIQdoYf6Dpyl2YSJSIE/uSARf/KY6jUzBRMB0GA1UdDgQWBBTq5Z7+F45t
+pxphB3pihNefSEzXdTAfBgNVHSMEGDAWgBTq5Z7+F45tpxphB3pihNefSEzXdTAP
+BgNVHRMBAf8EBTADAQH/MAoGCCqGSM49BAMCA0kAMEYCIQDOC8F8QDuRcPYzzy3J
+Fl2Bu8hQkyeimNIyNP5ouiYbbAIhAK58KrGd+VUFGZvopU4JRZxH6SiS6YSH65zb
+OhE2QvSw
+-----END CERTIFICATE-----`